@@ -0,0 +1,108 @@
+package gurnel
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// RefCorpus is a reference word-frequency table that a journal's own word
+// frequencies are compared against to find unusually over- or
+// under-represented words. The generated English corpus is one
+// implementation; LoadCorpusJSON provides another for corpora built by
+// ecosystem tools that pre-compute token frequencies from sample
+// directories.
+type RefCorpus interface {
+	Name() string
+	Freq(word string) float64
+	Size() int
+}
+
+var (
+	corporaMu sync.Mutex
+	corpora   = make(map[string]RefCorpus)
+)
+
+// RegisterCorpus makes a RefCorpus available by name to --corpus.
+// Corpora are typically registered from an init func.
+func RegisterCorpus(c RefCorpus) {
+	corporaMu.Lock()
+	defer corporaMu.Unlock()
+	corpora[c.Name()] = c
+}
+
+func namedCorpus(name string) (RefCorpus, bool) {
+	corporaMu.Lock()
+	defer corporaMu.Unlock()
+	c, ok := corpora[name]
+	return c, ok
+}
+
+// generatedEnglishCorpus adapts the package-level refFreqs populated by
+// `go generate` (see stats.go) to the RefCorpus interface, so it can be
+// selected and compared against like any other corpus.
+type generatedEnglishCorpus struct{}
+
+func (generatedEnglishCorpus) Name() string             { return "english" }
+func (generatedEnglishCorpus) Freq(word string) float64 { return refFreqs[word] }
+func (generatedEnglishCorpus) Size() int                { return len(refFreqs) }
+
+func init() {
+	RegisterCorpus(generatedEnglishCorpus{})
+}
+
+// jsonCorpus is a RefCorpus loaded from a {"tokens_total": N, "tokens":
+// {"word": count}} file via LoadCorpusJSON.
+type jsonCorpus struct {
+	name   string
+	tokens map[string]float64
+}
+
+func (c *jsonCorpus) Name() string             { return c.name }
+func (c *jsonCorpus) Freq(word string) float64 { return c.tokens[word] }
+func (c *jsonCorpus) Size() int                { return len(c.tokens) }
+
+// LoadCorpusJSON reads a reference corpus from the same
+// {"tokens_total": N, "tokens": {"word": count}} shape ecosystem tools use
+// for pre-computed token frequency tables over sample directories.
+func LoadCorpusJSON(path string) (RefCorpus, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.New("opening corpus " + err.Error())
+	}
+	defer f.Close()
+
+	var doc struct {
+		TokensTotal uint64            `json:"tokens_total"`
+		Tokens      map[string]uint64 `json:"tokens"`
+	}
+	if err := json.NewDecoder(f).Decode(&doc); err != nil {
+		return nil, errors.New("decoding corpus " + err.Error())
+	}
+	if doc.TokensTotal == 0 {
+		return nil, errors.New("corpus has a tokens_total of zero")
+	}
+
+	tokens := make(map[string]float64, len(doc.Tokens))
+	for word, count := range doc.Tokens {
+		tokens[word] = float64(count) / float64(doc.TokensTotal)
+	}
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	return &jsonCorpus{name: name, tokens: tokens}, nil
+}
+
+// resolveCorpus turns a --corpus flag value into a RefCorpus: the empty
+// string selects the generated English corpus, a registered name is looked
+// up directly, and anything else is treated as a path to a JSON corpus.
+func resolveCorpus(nameOrPath string) (RefCorpus, error) {
+	if nameOrPath == "" {
+		return generatedEnglishCorpus{}, nil
+	}
+	if c, ok := namedCorpus(nameOrPath); ok {
+		return c, nil
+	}
+	return LoadCorpusJSON(nameOrPath)
+}
@@ -0,0 +1,120 @@
+package gurnel
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikeraimondi/journalentry/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+// result is one scanned journal entry, or the error encountered reading it.
+type result struct {
+	path      string
+	wordMap   map[string]uint64
+	positions map[string][]int
+	date      time.Time
+	err       error
+}
+
+type wordStat struct {
+	word      string
+	frequency float64
+}
+
+// multiErr aggregates the per-entry errors a scan collects along the way,
+// so a handful of unreadable files doesn't hide the stats for everything
+// else in the tree.
+type multiErr []error
+
+func (m multiErr) Error() string {
+	msgs := make([]string, len(m))
+	for i, err := range m {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// scanTree walks root and scans every journal entry under it across jobs
+// scanner goroutines, returning a channel of results and the errgroup
+// coordinating the walker and scanners. The returned channel closes once
+// every entry has been scanned; call eg.Wait() afterward to learn whether
+// the walk itself failed (e.g. root doesn't exist or ctx was canceled) —
+// per-entry read errors are delivered as results, not through eg.
+func scanTree(ctx context.Context, root string, jobs int) (<-chan result, *errgroup.Group) {
+	if jobs < 1 {
+		jobs = 1
+	}
+	eg, ctx := errgroup.WithContext(ctx)
+	paths, errc := walkFiles(ctx, root)
+	eg.Go(func() error { return <-errc })
+
+	c := make(chan result)
+	var wg sync.WaitGroup
+	wg.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		eg.Go(func() error {
+			defer wg.Done()
+			entryScanner(ctx, paths, c)
+			return nil
+		})
+	}
+	go func() {
+		wg.Wait()
+		close(c)
+	}()
+	return c, eg
+}
+
+func walkFiles(ctx context.Context, root string) (<-chan string, <-chan error) {
+	paths := make(chan string)
+	errc := make(chan error, 1)
+	visited := make(map[string]bool)
+	go func() {
+		// Close the paths channel after Walk returns.
+		defer close(paths)
+		// No select needed for this send, since errc is buffered.
+		errc <- filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.Mode().IsRegular() || visited[info.Name()] || !journalentry.IsEntry(path) {
+				return nil
+			}
+			visited[info.Name()] = true
+			select {
+			case paths <- path:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		})
+	}()
+	return paths, errc
+}
+
+func entryScanner(ctx context.Context, paths <-chan string, c chan<- result) {
+	for path := range paths {
+		p := &journalentry.Entry{Path: path}
+		m := make(map[string]uint64)
+		positions := make(map[string][]int)
+		_, err := p.Load()
+		if err == nil {
+			for i, word := range p.Words() {
+				w := strings.ToLower(string(word))
+				m[w]++
+				positions[w] = append(positions[w], i)
+			}
+		}
+		date, _ := p.Date()
+		select {
+		case c <- result{path: path, date: date, wordMap: m, positions: positions, err: err}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
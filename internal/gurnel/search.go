@@ -0,0 +1,447 @@
+package gurnel
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mikeraimondi/journalentry/v2"
+	"golang.org/x/sync/errgroup"
+)
+
+// posting records where a token occurred within a single journal entry.
+type posting struct {
+	EntryPath string
+	Date      time.Time
+	Positions []int
+	Freq      int
+}
+
+// Index is an inverted index mapping lowercased tokens to the entries they
+// appear in. It is safe for concurrent use during construction via mu.
+type Index struct {
+	mu       sync.Mutex
+	Postings map[string][]posting
+	MTimes   map[string]time.Time
+	DocCount int
+}
+
+func newIndex() *Index {
+	return &Index{
+		Postings: make(map[string][]posting),
+		MTimes:   make(map[string]time.Time),
+	}
+}
+
+// addEntry folds one scanned entry's positions into the index, replacing any
+// prior postings for the same path.
+func (idx *Index) addEntry(path string, date time.Time, positions map[string][]int, mtime time.Time) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeEntryLocked(path)
+	for word, pos := range positions {
+		idx.Postings[word] = append(idx.Postings[word], posting{
+			EntryPath: path,
+			Date:      date,
+			Positions: pos,
+			Freq:      len(pos),
+		})
+	}
+	idx.MTimes[path] = mtime
+	idx.DocCount++
+}
+
+// removeEntry drops all postings for path, e.g. when the file has been
+// deleted since the index was last persisted.
+func (idx *Index) removeEntry(path string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.removeEntryLocked(path)
+}
+
+func (idx *Index) removeEntryLocked(path string) {
+	if _, ok := idx.MTimes[path]; !ok {
+		return
+	}
+	for word, postings := range idx.Postings {
+		kept := postings[:0]
+		for _, p := range postings {
+			if p.EntryPath != path {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			delete(idx.Postings, word)
+		} else {
+			idx.Postings[word] = kept
+		}
+	}
+	delete(idx.MTimes, path)
+	idx.DocCount--
+}
+
+func indexPath() (string, error) {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return "", errors.New("finding home directory " + err.Error())
+	}
+	return filepath.Join(home, ".gurnel", "index.gob"), nil
+}
+
+func loadIndex() (*Index, error) {
+	p, err := indexPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return newIndex(), nil
+	}
+	if err != nil {
+		return nil, errors.New("opening index " + err.Error())
+	}
+	defer f.Close()
+	idx := newIndex()
+	if err := gob.NewDecoder(f).Decode(idx); err != nil {
+		return nil, errors.New("decoding index " + err.Error())
+	}
+	return idx, nil
+}
+
+func (idx *Index) save() error {
+	p, err := indexPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o700); err != nil {
+		return errors.New("creating index dir " + err.Error())
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return errors.New("creating index file " + err.Error())
+	}
+	defer f.Close()
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	return gob.NewEncoder(f).Encode(idx)
+}
+
+// buildIndex walks root from scratch, scanning every journal entry found.
+func buildIndex(root string) (*Index, error) {
+	idx := newIndex()
+	if err := refreshIndex(idx, root); err != nil {
+		return nil, err
+	}
+	return idx, nil
+}
+
+// refreshIndex walks root and rescans only entries whose mtime is newer than
+// what's recorded in idx, removing postings for entries that no longer
+// exist. This lets repeat searches avoid rescanning the whole tree.
+func refreshIndex(idx *Index, root string) error {
+	seen := make(map[string]bool)
+	eg, ctx := errgroup.WithContext(context.Background())
+	paths, errc := walkFiles(ctx, root)
+	eg.Go(func() error { return <-errc })
+
+	stale := make(chan string)
+	eg.Go(func() error {
+		defer close(stale)
+		for path := range paths {
+			info, err := os.Stat(path)
+			if err != nil {
+				continue
+			}
+			seen[path] = true
+			idx.mu.Lock()
+			prev, ok := idx.MTimes[path]
+			idx.mu.Unlock()
+			if ok && !info.ModTime().After(prev) {
+				continue
+			}
+			select {
+			case stale <- path:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	})
+
+	c := make(chan result)
+	var wg sync.WaitGroup
+	jobs := runtime.NumCPU()
+	wg.Add(jobs)
+	for i := 0; i < jobs; i++ {
+		eg.Go(func() error {
+			defer wg.Done()
+			entryScanner(ctx, stale, c)
+			return nil
+		})
+	}
+	go func() {
+		wg.Wait()
+		close(c)
+	}()
+
+	var scanErrs multiErr
+	for r := range c {
+		if r.err != nil {
+			scanErrs = append(scanErrs, fmt.Errorf("%s: %w", r.path, r.err))
+			continue
+		}
+		info, err := os.Stat(r.path)
+		if err != nil {
+			continue
+		}
+		idx.addEntry(r.path, r.date, r.positions, info.ModTime())
+	}
+	if err := eg.Wait(); err != nil {
+		return err
+	}
+	if len(scanErrs) > 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d unreadable entries: %v\n", len(scanErrs), scanErrs)
+	}
+
+	for path := range idx.MTimes {
+		if !seen[path] {
+			idx.removeEntry(path)
+		}
+	}
+	return nil
+}
+
+type searchCmd struct {
+	phrase bool
+}
+
+func (*searchCmd) Name() string      { return "search" }
+func (*searchCmd) ShortHelp() string { return "Search journal entries" }
+func (*searchCmd) LongHelp() string  { return "TODO" }
+
+func (c *searchCmd) Flag() flag.FlagSet {
+	fs := flag.FlagSet{}
+	fs.BoolVar(&c.phrase, "phrase", false, "treat the query as an exact phrase rather than an AND of terms")
+	return fs
+}
+
+func (c *searchCmd) Run(conf *config, args []string) error {
+	if len(args) == 0 {
+		return errors.New("search requires a query")
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		return errors.New("getting working directory " + err.Error())
+	}
+	wd, err = filepath.EvalSymlinks(wd)
+	if err != nil {
+		return errors.New("evaluating symlinks " + err.Error())
+	}
+
+	idx, err := loadIndex()
+	if err != nil {
+		return err
+	}
+	if err := refreshIndex(idx, wd); err != nil {
+		return err
+	}
+	if err := idx.save(); err != nil {
+		return err
+	}
+
+	terms := make([]string, len(args))
+	for i, a := range args {
+		terms[i] = strings.ToLower(a)
+	}
+
+	var matches []scoredMatch
+	if c.phrase {
+		matches, err = idx.phraseSearch(terms)
+	} else {
+		matches, err = idx.andSearch(terms)
+	}
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(matches, func(i, j int) bool { return matches[i].score > matches[j].score })
+	const outFormat = "Jan 2 2006"
+	for _, m := range matches {
+		fmt.Printf("%v\t%.3f\t%v\n", m.date.Format(outFormat), m.score, m.snippet)
+	}
+	return nil
+}
+
+type scoredMatch struct {
+	entryPath string
+	date      time.Time
+	score     float64
+	snippet   string
+}
+
+// andSearch returns entries containing every term, ranked by summed tf-idf.
+func (idx *Index) andSearch(terms []string) ([]scoredMatch, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	byEntry := make(map[string]*scoredMatch)
+	matchCount := make(map[string]int)
+	for _, term := range terms {
+		postings, ok := idx.Postings[term]
+		if !ok {
+			return nil, nil // a missing term means no entry can match
+		}
+		idf := idx.idf(term)
+		for _, p := range postings {
+			m, ok := byEntry[p.EntryPath]
+			if !ok {
+				m = &scoredMatch{entryPath: p.EntryPath, date: p.Date}
+				byEntry[p.EntryPath] = m
+			}
+			m.score += float64(p.Freq) * idf
+			matchCount[p.EntryPath]++
+		}
+	}
+
+	var out []scoredMatch
+	for path, m := range byEntry {
+		if matchCount[path] != len(terms) {
+			continue
+		}
+		m.snippet = idx.snippet(path, terms[0])
+		out = append(out, *m)
+	}
+	return out, nil
+}
+
+// phraseSearch returns entries where terms occur consecutively, ranked by
+// the tf-idf of the rarest term in the phrase.
+func (idx *Index) phraseSearch(terms []string) ([]scoredMatch, error) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if len(terms) == 0 {
+		return nil, nil
+	}
+	first, ok := idx.Postings[terms[0]]
+	if !ok {
+		return nil, nil
+	}
+	rest := make([]map[string][]int, len(terms))
+	for i, term := range terms {
+		m := make(map[string][]int)
+		for _, p := range idx.Postings[term] {
+			m[p.EntryPath] = p.Positions
+		}
+		rest[i] = m
+	}
+
+	// The rarest term is the one with the highest idf.
+	rarest := 0
+	for i := range terms {
+		if idx.idf(terms[i]) > idx.idf(terms[rarest]) {
+			rarest = i
+		}
+	}
+	rarestIDF := idx.idf(terms[rarest])
+
+	var out []scoredMatch
+	for _, p := range first {
+		if !phraseOccursAt(rest, p.EntryPath) {
+			continue
+		}
+		out = append(out, scoredMatch{
+			entryPath: p.EntryPath,
+			date:      p.Date,
+			score:     float64(len(rest[rarest][p.EntryPath])) * rarestIDF,
+			snippet:   idx.snippet(p.EntryPath, terms[0]),
+		})
+	}
+	return out, nil
+}
+
+func phraseOccursAt(termPositions []map[string][]int, entryPath string) bool {
+	first := termPositions[0][entryPath]
+	for _, start := range first {
+		ok := true
+		for i := 1; i < len(termPositions); i++ {
+			if !containsInt(termPositions[i][entryPath], start+i) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			return true
+		}
+	}
+	return false
+}
+
+func containsInt(haystack []int, needle int) bool {
+	for _, v := range haystack {
+		if v == needle {
+			return true
+		}
+	}
+	return false
+}
+
+// idf returns the inverse document frequency of term. Callers must hold
+// idx.mu.
+func (idx *Index) idf(term string) float64 {
+	df := len(idx.Postings[term])
+	if df == 0 || idx.DocCount == 0 {
+		return 0
+	}
+	return math.Log(float64(idx.DocCount) / float64(df))
+}
+
+// snippet renders a short window of words around term's first occurrence in
+// path, for display alongside a match. Callers must hold idx.mu.
+func (idx *Index) snippet(path, term string) string {
+	var pos int
+	found := false
+	for _, p := range idx.Postings[term] {
+		if p.EntryPath == path && len(p.Positions) > 0 {
+			pos = p.Positions[0]
+			found = true
+			break
+		}
+	}
+	if !found {
+		return filepath.Base(path)
+	}
+
+	e := &journalentry.Entry{Path: path}
+	if _, err := e.Load(); err != nil {
+		return filepath.Base(path)
+	}
+	words := e.Words()
+	const window = 5
+	lo := pos - window
+	if lo < 0 {
+		lo = 0
+	}
+	hi := pos + window + 1
+	if hi > len(words) {
+		hi = len(words)
+	}
+	parts := make([]string, 0, hi-lo)
+	for _, w := range words[lo:hi] {
+		parts = append(parts, string(w))
+	}
+	return strings.Join(parts, " ")
+}
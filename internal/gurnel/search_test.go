@@ -0,0 +1,132 @@
+package gurnel
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// entryFileName returns a basename that satisfies journalentry.IsEntry, so
+// test fixtures are picked up by the real walkFiles/entryScanner pipeline.
+func entryFileName(date time.Time) string {
+	return date.Format("2006-01-02") + "-Journal-Entry-for-" + date.Format("Jan-2") + ".md"
+}
+
+func writeEntryFile(t *testing.T, dir string, date time.Time, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, entryFileName(date))
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("writing entry file: %v", err)
+	}
+	return path
+}
+
+func TestAndSearch(t *testing.T) {
+	idx := newIndex()
+	now := time.Now()
+	idx.addEntry("a.md", now, map[string][]int{"apple": {0, 5}, "banana": {2}}, now)
+	idx.addEntry("b.md", now, map[string][]int{"apple": {1}}, now)
+
+	matches, err := idx.andSearch([]string{"apple"})
+	if err != nil {
+		t.Fatalf("andSearch: %v", err)
+	}
+	if len(matches) != 2 {
+		t.Fatalf("got %d matches for a single shared term, want 2", len(matches))
+	}
+
+	matches, err = idx.andSearch([]string{"apple", "banana"})
+	if err != nil {
+		t.Fatalf("andSearch: %v", err)
+	}
+	if len(matches) != 1 || matches[0].entryPath != "a.md" {
+		t.Fatalf("got %v, want only a.md to match both terms", matches)
+	}
+
+	matches, err = idx.andSearch([]string{"cherry"})
+	if err != nil {
+		t.Fatalf("andSearch: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Fatalf("got %v matches for a term absent from the index, want none", matches)
+	}
+}
+
+func TestPhraseSearch(t *testing.T) {
+	idx := newIndex()
+	now := time.Now()
+	// "the quick fox" — "the" and "quick" are consecutive, "fox" and "the"
+	// are not.
+	idx.addEntry("c.md", now, map[string][]int{"the": {0}, "quick": {1}, "fox": {2}}, now)
+
+	matches, err := idx.phraseSearch([]string{"the", "quick"})
+	if err != nil {
+		t.Fatalf("phraseSearch: %v", err)
+	}
+	if len(matches) != 1 || matches[0].entryPath != "c.md" {
+		t.Fatalf("got %v, want c.md to match the consecutive phrase", matches)
+	}
+
+	matches, err = idx.phraseSearch([]string{"fox", "the"})
+	if err != nil {
+		t.Fatalf("phraseSearch: %v", err)
+	}
+	if len(matches) != 0 {
+		t.Fatalf("got %v, want no match for a non-consecutive phrase", matches)
+	}
+}
+
+func TestRefreshIndexIncremental(t *testing.T) {
+	dir := t.TempDir()
+	date := time.Date(2020, time.March, 1, 0, 0, 0, 0, time.UTC)
+	path := writeEntryFile(t, dir, date, "apple apple banana\n")
+
+	idx, err := buildIndex(dir)
+	if err != nil {
+		t.Fatalf("buildIndex: %v", err)
+	}
+	if len(idx.Postings["apple"]) != 1 {
+		t.Fatalf("got %d postings for %q, want 1", len(idx.Postings["apple"]), "apple")
+	}
+	if idx.DocCount != 1 {
+		t.Fatalf("got DocCount %d, want 1", idx.DocCount)
+	}
+
+	// Rewrite the entry with different content and force its mtime forward,
+	// since some filesystems have mtime resolution too coarse to guarantee
+	// the rewrite lands in a later tick than the original write.
+	if err := os.WriteFile(path, []byte("cherry\n"), 0o644); err != nil {
+		t.Fatalf("rewriting entry file: %v", err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes(path, future, future); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	if err := refreshIndex(idx, dir); err != nil {
+		t.Fatalf("refreshIndex: %v", err)
+	}
+	if len(idx.Postings["apple"]) != 0 {
+		t.Fatalf("got %d stale postings for %q after rewrite, want 0", len(idx.Postings["apple"]), "apple")
+	}
+	if len(idx.Postings["cherry"]) != 1 {
+		t.Fatalf("got %d postings for %q after rewrite, want 1", len(idx.Postings["cherry"]), "cherry")
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatalf("removing entry file: %v", err)
+	}
+	if err := refreshIndex(idx, dir); err != nil {
+		t.Fatalf("refreshIndex: %v", err)
+	}
+	if idx.DocCount != 0 {
+		t.Fatalf("got DocCount %d after removing the only entry, want 0", idx.DocCount)
+	}
+	if len(idx.Postings["cherry"]) != 0 {
+		t.Fatalf("got %d postings for %q after its file was removed, want 0", len(idx.Postings["cherry"]), "cherry")
+	}
+	if _, ok := idx.MTimes[path]; ok {
+		t.Fatalf("MTimes still tracks %q after its file was removed", path)
+	}
+}
@@ -1,32 +1,56 @@
 package gurnel
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
 	"math"
 	"os"
 	"path/filepath"
-	"sort"
-	"strings"
-	"sync"
+	"runtime"
 	"text/tabwriter"
 	"time"
-
-	"github.com/mikeraimondi/journalentry/v2"
 )
 
 //go:generate go run ../../scripts/generate_ref.go
 var refFreqs map[string]float64 // populated by generated code
 
-type statsCmd struct{}
+type statsCmd struct {
+	top    int
+	watch  bool
+	corpus string
+	output string
+	jobs   int
+}
+
+func (*statsCmd) Name() string      { return "stats" }
+func (*statsCmd) ShortHelp() string { return "View journal statistics" }
+func (*statsCmd) LongHelp() string  { return "TODO" }
 
-func (*statsCmd) Name() string       { return "stats" }
-func (*statsCmd) ShortHelp() string  { return "View journal statistics" }
-func (*statsCmd) LongHelp() string   { return "TODO" }
-func (*statsCmd) Flag() flag.FlagSet { return flag.FlagSet{} }
+func (c *statsCmd) Flag() flag.FlagSet {
+	fs := flag.FlagSet{}
+	fs.IntVar(&c.top, "top", 100, "number of unusually frequent/infrequent words to show")
+	fs.BoolVar(&c.watch, "watch", false, "watch the journal root and re-print stats as entries change")
+	fs.StringVar(&c.corpus, "corpus", "", "reference corpus to compare against: a registered name, or a path to a JSON corpus file (default: the built-in English corpus)")
+	fs.StringVar(&c.output, "output", "text", "output format: text or json")
+	fs.IntVar(&c.jobs, "jobs", runtime.NumCPU(), "number of concurrent entry scanners")
+	return fs
+}
+
+func (c *statsCmd) Run(conf *config, args []string) error {
+	if c.output != "text" && c.output != "json" {
+		return fmt.Errorf("unknown output format %q", c.output)
+	}
+	if c.jobs < 1 {
+		return fmt.Errorf("--jobs must be at least 1, got %d", c.jobs)
+	}
+	corpus, err := resolveCorpus(c.corpus)
+	if err != nil {
+		return err
+	}
 
-func (*statsCmd) Run(conf *config, args []string) error {
 	wd, err := os.Getwd()
 	if err != nil {
 		return errors.New("getting working directory " + err.Error())
@@ -36,30 +60,21 @@ func (*statsCmd) Run(conf *config, args []string) error {
 		return errors.New("evaluating symlinks " + err.Error())
 	}
 
-	done := make(chan struct{})
-	defer close(done)
-	paths, errc := walkFiles(done, wd)
-	c := make(chan result)
-	var wg sync.WaitGroup
-	const numScanners = 32
-	wg.Add(numScanners)
-	for i := 0; i < numScanners; i++ {
-		go func() {
-			entryScanner(done, paths, c)
-			wg.Done()
-		}()
-	}
-	go func() {
-		wg.Wait()
-		close(c)
-	}()
+	if c.watch {
+		return c.runWatch(wd, corpus)
+	}
+
+	ctx := context.Background()
+	results, eg := scanTree(ctx, wd, c.jobs)
 	var entryCount float64
 	wordMap := make(map[string]uint64)
 	t := time.Now()
 	minDate := t
-	for r := range c {
+	var scanErrs multiErr
+	for r := range results {
 		if r.err != nil {
-			return r.err
+			scanErrs = append(scanErrs, fmt.Errorf("%s: %w", r.path, r.err))
+			continue
 		}
 		entryCount++
 		for word, count := range r.wordMap {
@@ -69,33 +84,77 @@ func (*statsCmd) Run(conf *config, args []string) error {
 			minDate = r.date
 		}
 	}
-	// Check whether the Walk failed.
-	if err := <-errc; err != nil {
+	// Check whether the walk itself failed, e.g. root doesn't exist.
+	if err := eg.Wait(); err != nil {
 		return err
 	}
-	if entryCount > 0 {
-		percent := entryCount / math.Floor(t.Sub(minDate).Hours()/24)
-		const outFormat = "Jan 2 2006"
-		fmt.Printf("%.2f%% of days journaled since %v\n", percent*100, minDate.Format(outFormat))
-		var wordCount uint64
-		for _, count := range wordMap {
-			wordCount += count
+	if len(scanErrs) > 0 {
+		fmt.Fprintf(os.Stderr, "skipped %d unreadable entries: %v\n", len(scanErrs), scanErrs)
+	}
+	return reportStats(entryCount, wordMap, minDate, t, c.top, corpus, c.output)
+}
+
+// runWatch maintains a live Indexer over wd and re-prints stats after each
+// settled burst of filesystem changes, until interrupted.
+func (c *statsCmd) runWatch(wd string, corpus RefCorpus) error {
+	idx := newIndexer(wd, c.jobs)
+	if err := idx.scanAll(); err != nil {
+		return err
+	}
+	print := func() {
+		entryCount, wordMap, minDate := idx.aggregate()
+		if err := reportStats(float64(entryCount), wordMap, minDate, time.Now(), c.top, corpus, c.output); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return
 		}
-		fmt.Printf("Total word count: %v\n", wordCount)
-		avgCount := float64(wordCount) / entryCount
-		fmt.Printf("Average word count: %.1f\n", avgCount)
 		fmt.Print("\n")
+	}
+	print()
+	return idx.watch(nil, print)
+}
+
+// statsDoc is the --output json document: a structured form of the same
+// summary the text output prints, for downstream tools to consume.
+type statsDoc struct {
+	EntryCount           float64       `json:"entry_count"`
+	WordCount            uint64        `json:"word_count"`
+	AverageWordCount     float64       `json:"average_word_count"`
+	PercentDaysJournaled float64       `json:"percent_days_journaled"`
+	Corpus               string        `json:"corpus"`
+	TopUnusual           []wordStatDoc `json:"top_unusual"`
+	BottomUnusual        []wordStatDoc `json:"bottom_unusual"`
+}
 
-		if len(refFreqs) == 0 {
-			return nil // no code generation. exit early
+type wordStatDoc struct {
+	Word              string  `json:"word"`
+	RelativeFrequency float64 `json:"relative_frequency"`
+}
+
+// reportStats renders the summary statsCmd computes from already-aggregated
+// entryCount/wordMap/minDate, as text or as a statsDoc, depending on
+// output. now is the reference point percent-of-days-journaled is measured
+// against.
+func reportStats(entryCount float64, wordMap map[string]uint64, minDate, now time.Time, topN int, corpus RefCorpus, output string) error {
+	var wordCount uint64
+	for _, count := range wordMap {
+		wordCount += count
+	}
+
+	var percent, avgCount float64
+	if entryCount > 0 {
+		if days := math.Floor(now.Sub(minDate).Hours() / 24); days > 0 {
+			percent = entryCount / days
 		}
+		avgCount = float64(wordCount) / entryCount
+	}
 
-		wordStats := make([]*wordStat, len(wordMap))
-		i := 0
+	var top, bottom []wordStat
+	if entryCount > 0 && corpus.Size() > 0 {
+		relFreqs := make(map[string]float64, len(wordMap))
 		for word, count := range wordMap {
 			frequency := float64(count) / float64(wordCount)
 			var relFrequency float64
-			refFrequency := refFreqs[word]
+			refFrequency := corpus.Freq(word)
 			if frequency > refFrequency {
 				if refFrequency > 0 {
 					relFrequency = frequency / refFrequency
@@ -103,86 +162,59 @@ func (*statsCmd) Run(conf *config, args []string) error {
 			} else {
 				relFrequency = (refFrequency / frequency) * -1
 			}
-			wordStats[i] = &wordStat{word: word, occurrences: count, frequency: relFrequency}
-			i++
+			relFreqs[word] = relFrequency
 		}
+		top, bottom = TopK(relFreqs, topN)
+	}
 
-		sort.Slice(wordStats, func(i, j int) bool {
-			return wordStats[i].frequency > wordStats[j].frequency
-		})
-
-		topUnusualWordCount := 100
-		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
-		fmt.Printf("Top %v unusually frequent words:\n", topUnusualWordCount)
-		for _, ws := range wordStats[:topUnusualWordCount] {
-			fmt.Fprintf(w, "%v\t%.1fX\n", ws.word, ws.frequency)
-		}
-		w.Flush()
-		fmt.Print("\n")
-		fmt.Printf("Top %v unusually infrequent words:\n", topUnusualWordCount)
-		for i := 1; i <= topUnusualWordCount; i++ {
-			ws := wordStats[len(wordStats)-i]
-			fmt.Fprintf(w, "%v\t%.1fX\n", ws.word, ws.frequency)
+	if output == "json" {
+		doc := statsDoc{
+			EntryCount:           entryCount,
+			WordCount:            wordCount,
+			AverageWordCount:     avgCount,
+			PercentDaysJournaled: percent * 100,
+			Corpus:               corpus.Name(),
+			TopUnusual:           toWordStatDocs(top),
+			BottomUnusual:        toWordStatDocs(bottom),
 		}
-		w.Flush()
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
 	}
-	return nil
-}
 
-type result struct {
-	wordMap map[string]uint64
-	date    time.Time
-	err     error
-}
+	if entryCount == 0 {
+		return nil // nothing to print for an empty journal
+	}
 
-type wordStat struct {
-	word        string
-	occurrences uint64
-	frequency   float64
-}
+	const outFormat = "Jan 2 2006"
+	fmt.Printf("%.2f%% of days journaled since %v\n", percent*100, minDate.Format(outFormat))
+	fmt.Printf("Total word count: %v\n", wordCount)
+	fmt.Printf("Average word count: %.1f\n", avgCount)
+	fmt.Print("\n")
 
-func walkFiles(done <-chan struct{}, root string) (<-chan string, <-chan error) {
-	paths := make(chan string)
-	errc := make(chan error, 1)
-	visited := make(map[string]bool)
-	go func() {
-		// Close the paths channel after Walk returns.
-		defer close(paths)
-		// No select needed for this send, since errc is buffered.
-		errc <- filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-			if !info.Mode().IsRegular() || visited[info.Name()] || !journalentry.IsEntry(path) {
-				return nil
-			}
-			visited[info.Name()] = true
-			select {
-			case paths <- path:
-			case <-done:
-				return errors.New("walk canceled")
-			}
-			return nil
-		})
-	}()
-	return paths, errc
+	if corpus.Size() == 0 {
+		return nil // no reference corpus available to compare against
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Printf("Top %v unusually frequent words (vs %v corpus):\n", topN, corpus.Name())
+	for _, ws := range top {
+		fmt.Fprintf(w, "%v\t%.1fX\n", ws.word, ws.frequency)
+	}
+	w.Flush()
+	fmt.Print("\n")
+	fmt.Printf("Top %v unusually infrequent words (vs %v corpus):\n", topN, corpus.Name())
+	for _, ws := range bottom {
+		fmt.Fprintf(w, "%v\t%.1fX\n", ws.word, ws.frequency)
+	}
+	w.Flush()
+	return nil
 }
 
-func entryScanner(done <-chan struct{}, paths <-chan string, c chan<- result) {
-	for path := range paths {
-		p := &journalentry.Entry{Path: path}
-		m := make(map[string]uint64)
-		_, err := p.Load()
-		if err == nil {
-			for _, word := range p.Words() {
-				m[strings.ToLower(string(word))]++
-			}
-		}
-		date, _ := p.Date()
-		select {
-		case c <- result{date: date, wordMap: m, err: err}:
-		case <-done:
-			return
-		}
+func toWordStatDocs(stats []wordStat) []wordStatDoc {
+	docs := make([]wordStatDoc, len(stats))
+	for i, ws := range stats {
+		docs[i] = wordStatDoc{Word: ws.word, RelativeFrequency: ws.frequency}
 	}
-}
\ No newline at end of file
+	return docs
+}
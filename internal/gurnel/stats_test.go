@@ -0,0 +1,53 @@
+package gurnel
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// writeSyntheticJournal populates dir with n journal entries named by date,
+// each containing a handful of distinct words, so benchmarks have a tree to
+// walk and scan without depending on a real journal.
+func writeSyntheticJournal(b *testing.B, dir string, n int) {
+	b.Helper()
+	start := time.Date(2000, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < n; i++ {
+		date := start.AddDate(0, 0, i)
+		name := date.Format("2006-01-02") + "-Journal-Entry-for-" + date.Format("Jan-2") + ".md"
+		content := fmt.Sprintf("entry %d the quick brown fox jumps over the lazy dog\n", i)
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			b.Fatalf("writing synthetic entry: %v", err)
+		}
+	}
+}
+
+// BenchmarkScanTree guards against regressions in the walker/scanner
+// pipeline's throughput and goroutine coordination over a 10k-entry tree.
+func BenchmarkScanTree(b *testing.B) {
+	dir := b.TempDir()
+	const numEntries = 10000
+	writeSyntheticJournal(b, dir, numEntries)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		results, eg := scanTree(context.Background(), dir, runtime.NumCPU())
+		var entryCount int
+		for r := range results {
+			if r.err != nil {
+				b.Fatalf("unexpected scan error: %v", r.err)
+			}
+			entryCount++
+		}
+		if err := eg.Wait(); err != nil {
+			b.Fatalf("scanTree: %v", err)
+		}
+		if entryCount != numEntries {
+			b.Fatalf("got %d entries, want %d", entryCount, numEntries)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package gurnel
+
+import (
+	"container/heap"
+	"sort"
+)
+
+// TopK returns the k words with the highest frequency (top) and the k words
+// with the lowest frequency (bottom) from freqs, each using a bounded
+// min-heap rather than a full sort so callers that only need a handful of
+// extremes out of a large vocabulary don't pay to sort all of it.
+func TopK(freqs map[string]float64, k int) (top, bottom []wordStat) {
+	if k <= 0 {
+		return nil, nil
+	}
+	highest := &wordStatHeap{less: func(a, b wordStat) bool { return a.frequency < b.frequency }}
+	lowest := &wordStatHeap{less: func(a, b wordStat) bool { return a.frequency > b.frequency }}
+	for word, freq := range freqs {
+		ws := wordStat{word: word, frequency: freq}
+
+		heap.Push(highest, ws)
+		if highest.Len() > k {
+			heap.Pop(highest)
+		}
+
+		heap.Push(lowest, ws)
+		if lowest.Len() > k {
+			heap.Pop(lowest)
+		}
+	}
+
+	top = highest.items
+	sort.Slice(top, func(i, j int) bool { return top[i].frequency > top[j].frequency })
+	bottom = lowest.items
+	sort.Slice(bottom, func(i, j int) bool { return bottom[i].frequency < bottom[j].frequency })
+	return top, bottom
+}
+
+// wordStatHeap is a container/heap.Interface over wordStats ordered by less,
+// used to retain only the k most extreme entries seen so far.
+type wordStatHeap struct {
+	items []wordStat
+	less  func(a, b wordStat) bool
+}
+
+func (h wordStatHeap) Len() int            { return len(h.items) }
+func (h wordStatHeap) Less(i, j int) bool  { return h.less(h.items[i], h.items[j]) }
+func (h wordStatHeap) Swap(i, j int)       { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *wordStatHeap) Push(x interface{}) { h.items = append(h.items, x.(wordStat)) }
+func (h *wordStatHeap) Pop() interface{} {
+	old := h.items
+	n := len(old)
+	item := old[n-1]
+	h.items = old[:n-1]
+	return item
+}
@@ -0,0 +1,209 @@
+package gurnel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/mikeraimondi/journalentry/v2"
+)
+
+// debounceWindow coalesces the burst of events an editor's atomic-save
+// produces (rename into place, chmod, etc.) into a single rescan.
+const debounceWindow = 200 * time.Millisecond
+
+// entryStats is one journal entry's contribution to the aggregate stats
+// statsCmd reports.
+type entryStats struct {
+	wordMap map[string]uint64
+	date    time.Time
+}
+
+// Indexer maintains a live, incrementally-updated view of a journal root's
+// word counts, fed by fsnotify events rather than repeated full walks. The
+// visited-by-basename rule mirrors walkFiles: only the first file with a
+// given basename under root counts, so e.g. a checked-out backup copy isn't
+// double-counted.
+type Indexer struct {
+	root string
+	jobs int
+
+	mu      sync.Mutex
+	visited map[string]string // basename -> canonical path claiming it
+	entries map[string]entryStats
+}
+
+func newIndexer(root string, jobs int) *Indexer {
+	return &Indexer{
+		root:    root,
+		jobs:    jobs,
+		visited: make(map[string]string),
+		entries: make(map[string]entryStats),
+	}
+}
+
+// scanAll performs one full walk of idx.root to establish the initial
+// state, reusing the same walkFiles/entryScanner pipeline statsCmd uses for
+// one-shot runs.
+func (idx *Indexer) scanAll() error {
+	results, eg := scanTree(context.Background(), idx.root, idx.jobs)
+	for r := range results {
+		if r.err != nil {
+			continue
+		}
+		idx.set(r.path, r.wordMap, r.date)
+	}
+	return eg.Wait()
+}
+
+// set records or replaces path's contribution.
+func (idx *Indexer) set(path string, wordMap map[string]uint64, date time.Time) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	base := filepath.Base(path)
+	if claimant, ok := idx.visited[base]; ok && claimant != path {
+		return
+	}
+	idx.visited[base] = path
+	idx.entries[path] = entryStats{wordMap: wordMap, date: date}
+}
+
+// rescan re-reads a single entry and folds its updated contribution in,
+// used for fsnotify Create/Write events.
+func (idx *Indexer) rescan(path string) {
+	if !journalentry.IsEntry(path) {
+		return
+	}
+	p := &journalentry.Entry{Path: path}
+	if _, err := p.Load(); err != nil {
+		return
+	}
+	m := make(map[string]uint64)
+	for _, word := range p.Words() {
+		m[strings.ToLower(string(word))]++
+	}
+	date, _ := p.Date()
+	idx.set(path, m, date)
+}
+
+// remove subtracts path's contribution, or that of every entry beneath path
+// when it names a directory, used for fsnotify Remove/Rename events
+// (fsnotify fires Rename for the old name of a moved file or directory).
+func (idx *Indexer) remove(path string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	prefix := path + string(filepath.Separator)
+	for p := range idx.entries {
+		if p != path && !strings.HasPrefix(p, prefix) {
+			continue
+		}
+		if idx.visited[filepath.Base(p)] == p {
+			delete(idx.visited, filepath.Base(p))
+		}
+		delete(idx.entries, p)
+	}
+}
+
+// aggregate derives the same summary statsCmd.Run computes from a one-shot
+// walk, but from the Indexer's current incrementally-maintained state.
+func (idx *Indexer) aggregate() (entryCount int, wordMap map[string]uint64, minDate time.Time) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	wordMap = make(map[string]uint64)
+	minDate = time.Now()
+	for _, e := range idx.entries {
+		entryCount++
+		for word, count := range e.wordMap {
+			wordMap[word] += count
+		}
+		if minDate.After(e.date) {
+			minDate = e.date
+		}
+	}
+	return entryCount, wordMap, minDate
+}
+
+// watch subscribes to fsnotify events under idx.root and invokes onChange
+// (debounced) after each settled burst of changes. It blocks until done is
+// closed or the watcher errors.
+func (idx *Indexer) watch(done <-chan struct{}, onChange func()) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return errors.New("creating watcher " + err.Error())
+	}
+	defer w.Close()
+
+	if err := addWatchesRecursive(w, idx, idx.root); err != nil {
+		return err
+	}
+
+	var mu sync.Mutex
+	var timer *time.Timer
+	debounce := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		if timer != nil {
+			timer.Stop()
+		}
+		timer = time.AfterFunc(debounceWindow, onChange)
+	}
+
+	for {
+		select {
+		case ev, ok := <-w.Events:
+			if !ok {
+				return nil
+			}
+			info, statErr := os.Stat(ev.Name)
+			isDir := statErr == nil && info.IsDir()
+			switch {
+			case ev.Op&(fsnotify.Create|fsnotify.Write) != 0:
+				if isDir {
+					// A directory appeared (e.g. the new name of a
+					// renamed directory); re-add watches under it and
+					// scan the entries already inside it, or they'd stay
+					// silently missing from the stats until individually
+					// touched by a later event.
+					_ = addWatchesRecursive(w, idx, ev.Name)
+				} else {
+					idx.rescan(ev.Name)
+				}
+			case ev.Op&(fsnotify.Remove|fsnotify.Rename) != 0:
+				idx.remove(ev.Name)
+			}
+			debounce()
+		case err, ok := <-w.Errors:
+			if !ok {
+				return nil
+			}
+			return err
+		case <-done:
+			return nil
+		}
+	}
+}
+
+// addWatchesRecursive adds a watch on root and every subdirectory beneath
+// it, and folds in any journal entries already present under root. fsnotify
+// watches are not recursive and do not survive a directory being renamed
+// into place, so this is called again on the new path whenever a
+// Create/Write event reports a directory — at which point the directory's
+// contents are new to idx too, not just unwatched.
+func addWatchesRecursive(w *fsnotify.Watcher, idx *Indexer, root string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return nil // the directory may have vanished mid-rename; skip it
+		}
+		if info.IsDir() {
+			_ = w.Add(path)
+			return nil
+		}
+		idx.rescan(path)
+		return nil
+	})
+}